@@ -0,0 +1,258 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	pe "github.com/pkg/errors"
+)
+
+// partSuffix names the sidecar file that tracks in-progress chunk downloads,
+// so an interrupted download can resume instead of restarting from scratch.
+const partSuffix = ".part.json"
+
+// chunkState tracks a single byte range of a chunked download.
+type chunkState struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+	Done   bool  `json:"done"`
+}
+
+// downloadState is the sidecar persisted alongside a chunked download so it
+// can be resumed. It is keyed by URL and total size so a stale sidecar left
+// over from a different release is ignored rather than reused.
+type downloadState struct {
+	URL    string       `json:"url"`
+	Size   int64        `json:"size"`
+	Chunks []chunkState `json:"chunks"`
+}
+
+// downloadFile fetches target into dest. When the server advertises
+// Accept-Ranges: bytes and a Content-Length, the file is split into
+// n.Settings.DownloadChunks chunks downloaded concurrently with Range
+// requests; otherwise it falls back to a single plain GET. Progress is
+// reported to n.ioout unless silent is true. A chunked download that is
+// interrupted leaves a `.part.json` sidecar next to dest recording which
+// chunks completed, so the next invocation resumes only the missing ones.
+func (n *NVMG) downloadFile(client *http.Client, target *url.URL, dest string, silent bool) error {
+	head, err := client.Head(target.String())
+	if err != nil {
+		return pe.Wrapf(err, "HEAD request failed for %v", target.String())
+	}
+	head.Body.Close()
+
+	size := head.ContentLength
+	chunked := size > 0 && head.Header.Get("Accept-Ranges") == "bytes" && n.Settings.DownloadChunks > 1
+	if !chunked {
+		return n.downloadWhole(client, target, dest, size, silent)
+	}
+	return n.downloadChunked(client, target, dest, size, silent)
+}
+
+// downloadWhole performs a single, unranged GET, used when the server
+// doesn't support byte ranges or chunking was disabled.
+func (n *NVMG) downloadWhole(client *http.Client, target *url.URL, dest string, size int64, silent bool) error {
+	resp, err := client.Get(target.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return pe.Errorf("unexpected status downloading %v: %v", target.String(), resp.Status)
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	progress := newProgressReporter(n.ioout, size, silent)
+	go progress.run()
+	_, err = io.Copy(f, io.TeeReader(resp.Body, progressWriter{progress}))
+	progress.finish()
+	if err != nil {
+		return pe.Wrapf(err, "failed to download file: %v", dest)
+	}
+	return nil
+}
+
+// downloadChunked splits [0, size) into n.Settings.DownloadChunks ranges and
+// downloads them concurrently, resuming from dest's `.part.json` sidecar
+// when present.
+func (n *NVMG) downloadChunked(client *http.Client, target *url.URL, dest string, size int64, silent bool) error {
+	statePath := dest + partSuffix
+	state := loadDownloadState(statePath, target.String(), size)
+	if state == nil {
+		state = n.newDownloadState(target.String(), size)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+
+	progress := newProgressReporter(n.ioout, size, silent)
+	go progress.run()
+	for _, c := range state.Chunks {
+		if c.Done {
+			progress.add(c.Length)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]error, len(state.Chunks))
+	for i := range state.Chunks {
+		if state.Chunks[i].Done {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := state.Chunks[i]
+			if err := downloadChunk(client, target, f, c, progress); err != nil {
+				errs[i] = err
+				return
+			}
+			mu.Lock()
+			state.Chunks[i].Done = true
+			saveDownloadState(statePath, state)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	progress.finish()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	os.Remove(statePath)
+	return nil
+}
+
+// downloadChunk issues a single ranged GET for c and writes the response
+// body into f at c.Offset via WriteAt.
+func downloadChunk(client *http.Client, target *url.URL, f *os.File, c chunkState, progress *progressReporter) error {
+	req, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", c.Offset, c.Offset+c.Length-1))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return pe.Errorf("server did not honor range request for bytes=%d-%d: %v", c.Offset, c.Offset+c.Length-1, resp.Status)
+	}
+
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		nr, err := resp.Body.Read(buf)
+		if nr > 0 {
+			if _, werr := f.WriteAt(buf[:nr], c.Offset+written); werr != nil {
+				return werr
+			}
+			written += int64(nr)
+			progress.add(int64(nr))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// newDownloadState splits size bytes into n.Settings.DownloadChunks
+// roughly-equal chunks.
+func (n *NVMG) newDownloadState(url string, size int64) *downloadState {
+	count := n.Settings.DownloadChunks
+	if count < 1 {
+		count = 1
+	}
+	chunkSize := size / int64(count)
+	if chunkSize == 0 {
+		count = 1
+		chunkSize = size
+	}
+	chunks := make([]chunkState, 0, count)
+	var offset int64
+	for i := 0; i < count; i++ {
+		length := chunkSize
+		if i == count-1 {
+			length = size - offset
+		}
+		chunks = append(chunks, chunkState{Offset: offset, Length: length})
+		offset += length
+	}
+	return &downloadState{URL: url, Size: size, Chunks: chunks}
+}
+
+// loadDownloadState reads the sidecar at path, returning nil if it is
+// missing, unreadable, or doesn't match url/size (e.g. left over from a
+// different release).
+func loadDownloadState(path, url string, size int64) *downloadState {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var s downloadState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil
+	}
+	if s.URL != url || s.Size != size {
+		return nil
+	}
+	return &s
+}
+
+// saveDownloadState persists s to path as the resume sidecar.
+func saveDownloadState(path string, s *downloadState) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, os.FileMode(0644))
+}
+
+// progressWriter adapts a *progressReporter to io.Writer so it can be used
+// as the side channel of an io.TeeReader.
+type progressWriter struct {
+	progress *progressReporter
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.progress.add(int64(len(p)))
+	return len(p), nil
+}
@@ -26,23 +26,25 @@ Usage:
   nvmg --version                             Print out the latest released version of nvmg
   nvmg install [-s] <version>                Download and install a <version>, [-s] from source. Uses .nvmgrc if available
     --reinstall-packages-from=<version>     When installing, reinstall packages installed in <node|iojs|node version number>
+    --iojs                                  Install from the io.js distribution instead of node
   nvmg uninstall <version>                   Uninstall a version
-  nvmg use [--silent] <version>              Modify PATH to use <version>. Uses .nvmgrc if available
-  nvmg exec [--silent] <version> [<command>] Run <command> on <version>. Uses .nvmgrc if available
-  nvmg run [--silent] <version> [<args>]     Run 'node' on <version> with <args> as arguments. Uses .nvmgrc if available
+  nvmg use [--silent] [--iojs] <version>     Point the 'current' symlink at <version>. Uses .nvmgrc if available
+  nvmg exec [--silent] [--iojs] <version> [<command>] Run <command> on <version>. Uses .nvmgrc if available
+  nvmg run [--silent] [--iojs] <version> [<args>]     Run 'node' on <version> with <args> as arguments. Uses .nvmgrc if available
   nvmg current                               Display currently activated version
-  nvmg ls                                    List installed versions
-  nvmg ls <version>                          List versions matching a given description
-  nvmg ls-remote                             List remote versions available for install
+  nvmg ls [--iojs]                           List installed versions
+  nvmg ls [--iojs] <version>                 List versions matching a given description
+  nvmg ls-remote [--iojs]                    List remote versions available for install
   nvmg version <version>                     Resolve the given description to a single local version
   nvmg version-remote <version>              Resolve the given description to a single remote version
   nvmg deactivate                            Undo effects of 'nvmg' on current shell
   nvmg alias [<pattern>]                     Show all aliases beginning with <pattern>
   nvmg alias <name> <version>                Set an alias named <name> pointing to <version>
   nvmg unalias <name>                        Deletes the alias named <name>
-  nvmg reinstall-packages <version>          Reinstall global 'npm' packages contained in <version> to current version
+  nvmg config <key> [<value>]                Get or set a persistent setting (node_mirror, npm_mirror, proxy, verifyssl, arch, root, index_cache_ttl, download_chunks)
+  nvmg reinstall-packages [--dry-run] [--iojs] <version>  Reinstall global 'npm' packages contained in <version> to current version
   nvmg unload                                Unload 'nvmg' from shell
-  nvmg which [<version>]                     Display path to installed node version. Uses .nvmgrc if available
+  nvmg which [--iojs] [<version>]            Display path to installed node version. Uses .nvmgrc if available
 
 Example:
   nvmg install v0.10.32                  Install a specific version number
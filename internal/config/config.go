@@ -0,0 +1,161 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package config implements the persistent settings subsystem of nvmg.
+// Settings are stored as a single JSON file under $NVMG_DIR so that every
+// subcommand can load and honor them (mirrors, proxy, TLS verification, ...).
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	pe "github.com/pkg/errors"
+)
+
+// FileName is the name of the settings file stored under the nvmg home directory.
+const FileName = "settings.json"
+
+const (
+	// DefaultNodeMirror is the default distribution directory nvmg downloads node from.
+	DefaultNodeMirror = "https://nodejs.org/dist/"
+	// DefaultNpmMirror is the default registry used to resolve npm packages.
+	DefaultNpmMirror = "https://registry.npmjs.org/"
+	// DefaultIndexCacheTTLSeconds is how long the cached index.json is trusted before refetching.
+	DefaultIndexCacheTTLSeconds = 86400
+	// DefaultDownloadChunks is the number of concurrent ranged requests Install splits a download into.
+	DefaultDownloadChunks = 4
+)
+
+// Settings holds the persistent configuration of nvmg. It is loaded once at
+// startup and shared by every subcommand.
+type Settings struct {
+	NodeMirror           string `json:"node_mirror"`
+	NpmMirror            string `json:"npm_mirror"`
+	Proxy                string `json:"proxy"`
+	VerifySSL            bool   `json:"verifyssl"`
+	Arch                 string `json:"arch"`
+	Root                 string `json:"root"`
+	IndexCacheTTLSeconds int    `json:"index_cache_ttl"`
+	DownloadChunks       int    `json:"download_chunks"`
+}
+
+// Default returns the Settings nvmg falls back to when no settings file
+// exists yet.
+func Default() *Settings {
+	return &Settings{
+		NodeMirror:           DefaultNodeMirror,
+		NpmMirror:            DefaultNpmMirror,
+		VerifySSL:            true,
+		IndexCacheTTLSeconds: DefaultIndexCacheTTLSeconds,
+		DownloadChunks:       DefaultDownloadChunks,
+	}
+}
+
+// Load reads the settings file from dir. If the file does not exist yet,
+// Load returns Default() rather than an error.
+func Load(dir string) (*Settings, error) {
+	s := Default()
+	b, err := ioutil.ReadFile(filepath.Join(dir, FileName))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, pe.Wrapf(err, "couldn't read settings file in: %v", dir)
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, pe.Wrapf(err, "couldn't parse settings file: %v", filepath.Join(dir, FileName))
+	}
+	return s, nil
+}
+
+// Save persists s to the settings file under dir, creating dir if necessary.
+func (s *Settings) Save(dir string) error {
+	if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+		return pe.Wrapf(err, "couldn't create nvmg home directory: %v", dir)
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return pe.Wrap(err, "couldn't marshal settings")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, FileName), b, os.FileMode(0644)); err != nil {
+		return pe.Wrapf(err, "couldn't write settings file in: %v", dir)
+	}
+	return nil
+}
+
+// Get returns the string representation of the setting named key.
+func (s *Settings) Get(key string) (string, error) {
+	switch key {
+	case "node_mirror":
+		return s.NodeMirror, nil
+	case "npm_mirror":
+		return s.NpmMirror, nil
+	case "proxy":
+		return s.Proxy, nil
+	case "verifyssl":
+		return fmt.Sprintf("%v", s.VerifySSL), nil
+	case "arch":
+		return s.Arch, nil
+	case "root":
+		return s.Root, nil
+	case "index_cache_ttl":
+		return fmt.Sprintf("%v", s.IndexCacheTTLSeconds), nil
+	case "download_chunks":
+		return fmt.Sprintf("%v", s.DownloadChunks), nil
+	default:
+		return "", pe.Errorf("unknown setting: %v", key)
+	}
+}
+
+// Set updates the setting named key to value.
+func (s *Settings) Set(key, value string) error {
+	switch key {
+	case "node_mirror":
+		s.NodeMirror = value
+	case "npm_mirror":
+		s.NpmMirror = value
+	case "proxy":
+		s.Proxy = value
+	case "verifyssl":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return pe.Wrapf(err, "verifyssl must be true or false: %v", value)
+		}
+		s.VerifySSL = b
+	case "arch":
+		s.Arch = value
+	case "root":
+		s.Root = value
+	case "index_cache_ttl":
+		ttl, err := strconv.Atoi(value)
+		if err != nil {
+			return pe.Wrapf(err, "index_cache_ttl must be an integer number of seconds: %v", value)
+		}
+		s.IndexCacheTTLSeconds = ttl
+	case "download_chunks":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return pe.Wrapf(err, "download_chunks must be an integer: %v", value)
+		}
+		s.DownloadChunks = n
+	default:
+		return pe.Errorf("unknown setting: %v", key)
+	}
+	return nil
+}
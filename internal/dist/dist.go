@@ -0,0 +1,122 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package dist abstracts over the JavaScript runtime distributions nvmg can
+// install: nodejs.org's node and the now-merged io.js fork.
+package dist
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Distribution identifies a source of prebuilt releases.
+type Distribution struct {
+	// Name is also the release archive's filename prefix and, for non-Node
+	// distributions, the on-disk subdirectory under $NVMG_DIR.
+	Name       string
+	BaseURL    string
+	IndexURL   string
+	BinaryName string
+}
+
+var (
+	// Node is the default, and original, distribution: nodejs.org/dist.
+	Node = Distribution{
+		Name:       "node",
+		BaseURL:    "https://nodejs.org/dist/",
+		IndexURL:   "https://nodejs.org/dist/index.json",
+		BinaryName: "node",
+	}
+	// IOJS is the io.js fork that existed prior to its 2015 merger back into Node.
+	IOJS = Distribution{
+		Name:       "iojs",
+		BaseURL:    "https://iojs.org/dist/",
+		IndexURL:   "https://iojs.org/dist/index.json",
+		BinaryName: "iojs",
+	}
+)
+
+// ByFlag returns IOJS when iojs is true, Node otherwise. Meant for an
+// explicit `--iojs` command-line flag.
+func ByFlag(iojs bool) Distribution {
+	if iojs {
+		return IOJS
+	}
+	return Node
+}
+
+// Detect infers the distribution a bare version descriptor belongs to: the
+// explicit "iojs" alias and "iojs-<descriptor>" prefix always mean IOJS, and
+// so - following the rule gnvm uses - does a bare major version in the 1-3
+// range, since that's the only range io.js ever released. Everything else is
+// assumed to be Node. It returns the distribution together with descriptor
+// stripped of any distribution prefix.
+func Detect(descriptor string) (Distribution, string) {
+	d := strings.TrimSpace(descriptor)
+	switch {
+	case d == "iojs":
+		return IOJS, "latest"
+	case strings.HasPrefix(d, "iojs-"):
+		return IOJS, strings.TrimPrefix(d, "iojs-")
+	case looksLikeIOJSVersion(d):
+		return IOJS, d
+	default:
+		return Node, d
+	}
+}
+
+func looksLikeIOJSVersion(d string) bool {
+	major := strings.SplitN(strings.TrimPrefix(d, "v"), ".", 2)[0]
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return false
+	}
+	return n >= 1 && n <= 3
+}
+
+// Ref identifies one installed or installable release: a Distribution plus
+// the resolved version string it returned (e.g. "v10.24.1").
+type Ref struct {
+	Distribution Distribution
+	Version      string
+}
+
+// Dir returns ref's install directory relative to $NVMG_DIR: just the
+// version for Node, or "iojs/<version>" for io.js, so the two runtimes never
+// collide on disk.
+func (r Ref) Dir() string {
+	if r.Distribution.Name == Node.Name {
+		return r.Version
+	}
+	return path.Join(r.Distribution.Name, r.Version)
+}
+
+// ArchiveName builds the filename of the release archive uploaded for ref on
+// the given platform/arch/ext, e.g. "node-v10.24.1-linux-x64.tar.gz" or
+// "iojs-v3.3.1-linux-x64.tar.gz".
+func (r Ref) ArchiveName(platform, arch, ext string) string {
+	return fmt.Sprintf("%v-%v-%v-%v.%v", r.Distribution.Name, r.Version, platform, arch, ext)
+}
+
+// String renders ref the way a user would type it back in to nvmg, e.g.
+// "v10.24.1" or "iojs-v3.3.1".
+func (r Ref) String() string {
+	if r.Distribution.Name == Node.Name {
+		return r.Version
+	}
+	return fmt.Sprintf("%v-%v", r.Distribution.Name, r.Version)
+}
@@ -0,0 +1,231 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package index fetches and caches nodejs.org's index.json and resolves
+// version descriptors (exact, partial, wildcard, regex, and LTS aliases)
+// against it.
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	pe "github.com/pkg/errors"
+)
+
+// cacheFileName is the name index.json is cached under inside the cache directory.
+const cacheFileName = "index.json"
+
+// LTSCodename holds the `lts` field of an index.json entry, which is either
+// `false` for non-LTS releases or the release's codename (e.g. "argon").
+type LTSCodename string
+
+// UnmarshalJSON accepts both the boolean and string shapes nodejs.org uses.
+func (l *LTSCodename) UnmarshalJSON(b []byte) error {
+	var codename string
+	if err := json.Unmarshal(b, &codename); err == nil {
+		*l = LTSCodename(codename)
+		return nil
+	}
+	var isLTS bool
+	if err := json.Unmarshal(b, &isLTS); err == nil {
+		*l = ""
+		return nil
+	}
+	return fmt.Errorf("index: unexpected value for lts field: %s", b)
+}
+
+// Entry is a single release record from index.json.
+type Entry struct {
+	Version string      `json:"version"`
+	NPM     string      `json:"npm"`
+	LTS     LTSCodename `json:"lts"`
+}
+
+// semver returns the entry's version parsed as a semver.Version.
+func (e Entry) semver() (semver.Version, error) {
+	return semver.Parse(strings.TrimPrefix(e.Version, "v"))
+}
+
+// Index is the parsed, semver-sorted (newest first) contents of index.json.
+type Index struct {
+	Entries []Entry
+}
+
+// Load returns the Index cached under cacheDir if it is newer than ttl,
+// otherwise it fetches a fresh copy from url via client and refreshes the
+// cache.
+func Load(client *http.Client, url, cacheDir string, ttl time.Duration) (*Index, error) {
+	cachePath := filepath.Join(cacheDir, cacheFileName)
+	if fi, err := os.Stat(cachePath); err == nil && time.Since(fi.ModTime()) < ttl {
+		if idx, err := loadFile(cachePath); err == nil {
+			return idx, nil
+		}
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, pe.Wrapf(err, "couldn't fetch %v", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, pe.Errorf("unexpected status fetching %v: %v", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, pe.Wrap(err, "couldn't read index.json response")
+	}
+
+	idx, err := parse(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cacheDir, os.FileMode(0755)); err == nil {
+		ioutil.WriteFile(cachePath, b, os.FileMode(0644))
+	}
+	return idx, nil
+}
+
+func loadFile(path string) (*Index, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(b)
+}
+
+func parse(b []byte) (*Index, error) {
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, pe.Wrap(err, "couldn't parse index.json")
+	}
+	return New(entries), nil
+}
+
+// New builds an Index out of entries already in hand - e.g. synthesized from
+// installed versions on disk rather than fetched from a remote index.json -
+// applying the same newest-first semver ordering Load produces.
+func New(entries []Entry) *Index {
+	sort.SliceStable(entries, func(i, j int) bool {
+		vi, ei := entries[i].semver()
+		vj, ej := entries[j].semver()
+		if ei != nil || ej != nil {
+			return false
+		}
+		return vi.GT(vj)
+	})
+	return &Index{Entries: entries}
+}
+
+// Match returns every entry matching descriptor, sorted newest first.
+// descriptor may be an exact version ("v10.24.1"), a partial version
+// ("10", "10.24"), a wildcard ("10.*", "*.24.*"), a slash-delimited regex
+// ("/^12\.[12]\./"), "*" (everything), or one of the aliases "latest",
+// "stable", "unstable", "lts/*", "lts/<codename>".
+func (idx *Index) Match(descriptor string) ([]Entry, error) {
+	pred, err := idx.predicate(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	var matched []Entry
+	for _, e := range idx.Entries {
+		if pred(e) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, pe.Errorf("no version found matching %v", descriptor)
+	}
+	return matched, nil
+}
+
+// Resolve returns the newest version matching descriptor. See Match for the
+// supported descriptor forms.
+func (idx *Index) Resolve(descriptor string) (string, error) {
+	matched, err := idx.Match(descriptor)
+	if err != nil {
+		return "", err
+	}
+	return matched[0].Version, nil
+}
+
+func (idx *Index) predicate(descriptor string) (func(Entry) bool, error) {
+	d := strings.TrimSpace(descriptor)
+	switch {
+	case d == "" || d == "*":
+		return func(Entry) bool { return true }, nil
+	case d == "latest" || d == "node":
+		return func(Entry) bool { return true }, nil
+	case d == "stable":
+		return func(e Entry) bool { return e.LTS != "" }, nil
+	case d == "unstable":
+		return func(e Entry) bool { return e.LTS == "" }, nil
+	case d == "lts/*":
+		return func(e Entry) bool { return e.LTS != "" }, nil
+	case strings.HasPrefix(d, "lts/"):
+		codename := strings.TrimPrefix(d, "lts/")
+		return func(e Entry) bool { return strings.EqualFold(string(e.LTS), codename) }, nil
+	case strings.HasPrefix(d, "/") && strings.HasSuffix(d, "/") && len(d) > 1:
+		re, err := regexp.Compile(strings.Trim(d, "/"))
+		if err != nil {
+			return nil, pe.Wrapf(err, "invalid regex descriptor: %v", d)
+		}
+		return func(e Entry) bool { return re.MatchString(strings.TrimPrefix(e.Version, "v")) }, nil
+	default:
+		pattern := strings.TrimPrefix(d, "v")
+		parts := strings.Split(pattern, ".")
+		for _, p := range parts {
+			if p == "" {
+				return nil, pe.Errorf("invalid version descriptor: %v", d)
+			}
+			if p == "*" {
+				continue
+			}
+			if _, err := strconv.ParseUint(p, 10, 64); err != nil {
+				return nil, pe.Errorf("invalid version descriptor: %v", d)
+			}
+		}
+		for len(parts) < 3 {
+			parts = append(parts, "*")
+		}
+		return func(e Entry) bool {
+			v, err := e.semver()
+			if err != nil {
+				return false
+			}
+			comps := []uint64{v.Major, v.Minor, v.Patch}
+			for i := 0; i < 3; i++ {
+				if parts[i] == "*" {
+					continue
+				}
+				n, _ := strconv.ParseUint(parts[i], 10, 64)
+				if n != comps[i] {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+}
@@ -0,0 +1,70 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package index
+
+import "testing"
+
+func testIndex(t *testing.T) *Index {
+	t.Helper()
+	raw := []byte(`[
+		{"version": "v12.2.0", "npm": "6.9.0", "lts": false},
+		{"version": "v12.1.0", "npm": "6.9.0", "lts": false},
+		{"version": "v10.24.1", "npm": "6.14.12", "lts": "Dubnium"},
+		{"version": "v10.20.0", "npm": "6.14.4", "lts": "Dubnium"},
+		{"version": "v8.17.0", "npm": "6.13.4", "lts": "Carbon"}
+	]`)
+	idx, err := parse(raw)
+	if err != nil {
+		t.Fatalf("parse() returned unexpected error: %v", err)
+	}
+	return idx
+}
+
+func TestResolve(t *testing.T) {
+	idx := testIndex(t)
+	cases := []struct {
+		descriptor string
+		want       string
+	}{
+		{"v10.24.1", "v10.24.1"},
+		{"10", "v10.24.1"},
+		{"10.24", "v10.24.1"},
+		{"10.*", "v10.24.1"},
+		{"*.20.*", "v10.20.0"},
+		{"latest", "v12.2.0"},
+		{"stable", "v10.24.1"},
+		{"unstable", "v12.2.0"},
+		{"lts/*", "v10.24.1"},
+		{"lts/carbon", "v8.17.0"},
+		{"/^12\\.[12]\\./", "v12.2.0"},
+	}
+	for _, c := range cases {
+		got, err := idx.Resolve(c.descriptor)
+		if err != nil {
+			t.Errorf("Resolve(%q) returned unexpected error: %v", c.descriptor, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Resolve(%q) = %v, want %v", c.descriptor, got, c.want)
+		}
+	}
+}
+
+func TestResolveNoMatch(t *testing.T) {
+	idx := testIndex(t)
+	if _, err := idx.Resolve("99"); err == nil {
+		t.Error("Resolve(\"99\") expected an error, got nil")
+	}
+}
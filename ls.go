@@ -0,0 +1,141 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/olekukonko/tablewriter"
+
+	pe "github.com/pkg/errors"
+
+	"github.com/ymotongpoo/nvmg/internal/dist"
+	"github.com/ymotongpoo/nvmg/internal/index"
+)
+
+// RunLs parses the arguments for the `ls` subcommand and runs it accordingly.
+// With no argument it lists every locally installed version; given a
+// version descriptor it lists the installed versions matching it.
+func (n *NVMG) RunLs() error {
+	flags := flag.NewFlagSet("lsFlags", flag.ExitOnError)
+	iojsFlag := flags.Bool("iojs", false, "List io.js releases instead of node.")
+	flags.Parse(n.args[2:])
+	return n.runLs(flags.Arg(0), true, *iojsFlag)
+}
+
+// RunLsRemote parses the arguments for the `ls-remote` subcommand and runs
+// it accordingly, listing every remote version matching the descriptor
+// regardless of whether it is installed.
+func (n *NVMG) RunLsRemote() error {
+	flags := flag.NewFlagSet("lsRemoteFlags", flag.ExitOnError)
+	iojsFlag := flags.Bool("iojs", false, "List io.js releases instead of node.")
+	flags.Parse(n.args[2:])
+	return n.runLs(flags.Arg(0), false, *iojsFlag)
+}
+
+func (n *NVMG) runLs(descriptor string, installedOnly, iojsFlag bool) error {
+	if descriptor == "" {
+		descriptor = "*"
+	}
+	d, rest := dist.Detect(descriptor)
+	if iojsFlag {
+		d = dist.IOJS
+	}
+
+	var idx *index.Index
+	if installedOnly {
+		// Plain `ls` enumerates $NVMG_DIR itself rather than index.json, so it
+		// works offline and still finds versions that have aged out of the
+		// remote index.
+		entries, err := n.installedEntries(d)
+		if err != nil {
+			return err
+		}
+		idx = index.New(entries)
+	} else {
+		var err error
+		idx, err = n.loadIndex(d)
+		if err != nil {
+			return err
+		}
+	}
+	entries, err := idx.Match(rest)
+	if err != nil {
+		return err
+	}
+	return n.printVersionTable(d, entries, installedOnly)
+}
+
+// installedEntries synthesizes one index.Entry per version of d installed
+// under $NVMG_DIR (or its iojs/ subtree), carrying only the Version field
+// since LTS codename and bundled npm version aren't known without
+// index.json.
+func (n *NVMG) installedEntries(d dist.Distribution) ([]index.Entry, error) {
+	base := n.versionRoot()
+	if d.Name == dist.IOJS.Name {
+		base = path.Join(n.versionRoot(), dist.IOJS.Name)
+	}
+	infos, err := ioutil.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, pe.Wrapf(err, "couldn't read %v", base)
+	}
+	var entries []index.Entry
+	for _, fi := range infos {
+		if !fi.IsDir() {
+			continue
+		}
+		ref := dist.Ref{Distribution: d, Version: fi.Name()}
+		if !n.isInstalled(ref) {
+			continue
+		}
+		entries = append(entries, index.Entry{Version: fi.Name()})
+	}
+	return entries, nil
+}
+
+func (n *NVMG) printVersionTable(d dist.Distribution, entries []index.Entry, installedOnly bool) error {
+	table := tablewriter.NewWriter(n.ioout)
+	table.SetHeader([]string{"Version", "LTS", "npm", "Installed"})
+	for _, e := range entries {
+		ref := dist.Ref{Distribution: d, Version: e.Version}
+		installed := n.isInstalled(ref)
+		if installedOnly && !installed {
+			continue
+		}
+		lts := string(e.LTS)
+		if lts == "" {
+			lts = "-"
+		}
+		yn := "no"
+		if installed {
+			yn = "yes"
+		}
+		table.Append([]string{ref.String(), lts, e.NPM, yn})
+	}
+	table.Render()
+	return nil
+}
+
+// isInstalled reports whether ref has a bin directory under the version root.
+func (n *NVMG) isInstalled(ref dist.Ref) bool {
+	_, err := os.Stat(path.Join(n.versionRoot(), ref.Dir(), "bin"))
+	return err == nil
+}
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"io"
@@ -25,23 +26,19 @@ import (
 	"path"
 	"runtime"
 	"strings"
+	"time"
 
-	"github.com/blang/semver"
 	"github.com/mholt/archiver"
 	pe "github.com/pkg/errors"
-)
-
-const (
-	// Version follows semver.
-	Version = "0.1.0"
 
-	// NodeDistributionURL is the URL of Node.js distribution list.
-	NodeDistributionURL = "https://nodejs.org/dist/"
-
-	// NodeIndexURL is the URL of index.json
-	NodeIndexURL = "https://nodejs.org/dist/index.json"
+	"github.com/ymotongpoo/nvmg/internal/config"
+	"github.com/ymotongpoo/nvmg/internal/dist"
+	"github.com/ymotongpoo/nvmg/internal/index"
 )
 
+// Version follows semver.
+const Version = "0.1.0"
+
 type NVMGError struct {
 	ErrorString string
 }
@@ -61,6 +58,8 @@ type NVMG struct {
 	versionFlag *bool
 	helpFlag    *bool
 	Home        string
+	// Settings holds the persistent configuration loaded from $NVMG_DIR/settings.json.
+	Settings *config.Settings
 }
 
 // NewNVMG returns a new instance of NVMG with the initialization of parsing arguments.
@@ -72,6 +71,12 @@ func NewNVMG(args []string, home string) (*NVMG, error) {
 		Home:  home,
 	}
 
+	settings, err := config.Load(home)
+	if err != nil {
+		return nil, pe.Wrap(err, "could not load settings")
+	}
+	nvmg.Settings = settings
+
 	flags := flag.NewFlagSet("nvmgFlags", flag.ExitOnError)
 	flags.SetOutput(nvmg.ioerr)
 	flags.Usage = func() {
@@ -119,19 +124,30 @@ func (n *NVMG) Run() error {
 	case "uninstall", "remove", "delete":
 		n.printfOut("uninstall") // TODO: replace here to actual command.
 	case "use":
+		return n.RunUse()
 	case "exec":
+		return n.RunExec()
 	case "run":
+		return n.RunRun()
 	case "current":
+		return n.RunCurrent()
 	case "ls":
+		return n.RunLs()
 	case "ls-remote":
+		return n.RunLsRemote()
 	case "version":
 	case "version-remote":
 	case "deactivate":
+		return n.Deactivate()
 	case "alias":
 	case "unalias":
 	case "reinstall-packages":
+		return n.RunReinstallPackages()
 	case "unload":
 	case "which":
+		return n.RunWhich()
+	case "config":
+		return n.RunConfig()
 	case "help":
 	default:
 	}
@@ -152,44 +168,142 @@ func (n *NVMG) printHelp() {
 
 // RunInstall parses the arguments for `install` subcommand and runs it accordingly.
 func (n *NVMG) RunInstall() error {
-	if len(n.args) < 2 {
+	if len(n.args) < 3 {
 		return fmt.Errorf("not enough arguments: %v", n.args)
 	}
 	flags := flag.NewFlagSet("installFlags", flag.ExitOnError)
 	ltsFlag := flags.Bool("lts", false, "Refer to the Long-term support version for aliases.")
-	flags.Parse(n.args[1:])
+	verifyFlag := flags.Bool("verify", false, "Verify the downloaded archive's GPG signature in addition to its SHA-256 checksum.")
+	silentFlag := flags.Bool("silent", false, "Suppress the download progress bar.")
+	iojsFlag := flags.Bool("iojs", false, "Install from the io.js distribution instead of node.")
+	reinstallFromFlag := flags.String("reinstall-packages-from", "", "Reinstall the global npm packages from <node|iojs|node version number> after installing.")
+	flags.Parse(n.args[2:])
 	if flags.NArg() < 1 {
 		return fmt.Errorf("not enough arguments for install: %v", flags.Args())
 	}
 	_ = ltsFlag // TODO: implement LTS context switch.
-	ver, err := n.expandVersionNumber(flags.Arg(1))
+	ref, err := n.expandVersionNumber(flags.Arg(0), *iojsFlag)
 	if err != nil {
 		return err
 	}
-	return n.Install(ver)
+	if err := n.Install(ref, *verifyFlag, *silentFlag); err != nil {
+		return err
+	}
+	if *reinstallFromFlag != "" {
+		from, err := n.expandVersionNumber(*reinstallFromFlag, *iojsFlag)
+		if err != nil {
+			return err
+		}
+		return n.ReinstallPackages(from, ref, false)
+	}
+	return nil
+}
+
+// RunConfig parses the arguments for the `config` subcommand and runs it
+// accordingly. With no value given, it prints the current value of <key>;
+// with a value given, it persists the new value to $NVMG_DIR/settings.json.
+func (n *NVMG) RunConfig() error {
+	if len(n.args) < 3 {
+		return fmt.Errorf("not enough arguments: %v", n.args)
+	}
+	key := n.args[2]
+	if len(n.args) < 4 {
+		v, err := n.Settings.Get(key)
+		if err != nil {
+			return err
+		}
+		n.printfOut(v)
+		return nil
+	}
+	if err := n.Settings.Set(key, n.args[3]); err != nil {
+		return err
+	}
+	return n.Settings.Save(n.Home)
+}
+
+// httpClient returns an *http.Client configured according to n.Settings:
+// it routes through Settings.Proxy when set and skips TLS verification
+// when Settings.VerifySSL is false.
+func (n *NVMG) httpClient() (*http.Client, error) {
+	transport := &http.Transport{}
+	if n.Settings.Proxy != "" {
+		p, err := url.Parse(n.Settings.Proxy)
+		if err != nil {
+			return nil, pe.Wrapf(err, "invalid proxy setting: %v", n.Settings.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(p)
+	}
+	if !n.Settings.VerifySSL {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport}, nil
 }
 
-// expandVersionNumber checks if the version number is valid and return
-func (n *NVMG) expandVersionNumber(ver string) (string, error) {
-	if strings.HasPrefix(ver, "v") {
-		ver = ver[1:]
+// expandVersionNumber resolves a version descriptor (an exact, partial, or
+// wildcard version, a slash-delimited regex, or an alias such as "latest",
+// "stable", or "lts/argon") to the single newest matching release, fetching
+// and caching that distribution's index.json as needed. The distribution is
+// inferred from descriptor (e.g. an "iojs-" prefix or a bare io.js-era major
+// version) unless iojsFlag forces it to io.js.
+func (n *NVMG) expandVersionNumber(descriptor string, iojsFlag bool) (*dist.Ref, error) {
+	d, rest := dist.Detect(descriptor)
+	if iojsFlag {
+		d = dist.IOJS
 	}
-	if ver == "stable" {
-		// TODO: implement here.
+	idx, err := n.loadIndex(d)
+	if err != nil {
+		return nil, err
 	}
-	v, err := semver.Parse(ver)
+	resolved, err := idx.Resolve(rest)
 	if err != nil {
-		return "", pe.Wrapf(err, "invalid version number: %v", ver)
+		return nil, pe.Wrapf(err, "invalid version number: %v", descriptor)
 	}
-	return fmt.Sprintf("v%v", v.String()), nil
+	return &dist.Ref{Distribution: d, Version: resolved}, nil
+}
+
+// loadIndex fetches (or serves from cache) d's parsed index.json. Each
+// distribution is cached separately under $NVMG_DIR/cache/<distribution>.
+func (n *NVMG) loadIndex(d dist.Distribution) (*index.Index, error) {
+	client, err := n.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	cacheDir := path.Join(n.Home, "cache", d.Name)
+	ttl := time.Duration(n.Settings.IndexCacheTTLSeconds) * time.Second
+	return index.Load(client, d.IndexURL, cacheDir, ttl)
+}
+
+// distMirror returns the distribution directory ref should be downloaded
+// from: the user-configurable node_mirror setting for Node, and io.js's
+// upstream directory (not yet mirror-configurable) for IOJS.
+func (n *NVMG) distMirror(d dist.Distribution) string {
+	if d.Name == dist.Node.Name {
+		return n.Settings.NodeMirror
+	}
+	return d.BaseURL
+}
+
+// versionRoot returns the directory installed versions live under: the
+// user-configurable root setting when set (mirroring nvm-windows, which
+// keeps installed versions separate from its own program directory), and
+// n.Home otherwise. Settings and the index cache always stay under n.Home.
+func (n *NVMG) versionRoot() string {
+	if n.Settings.Root != "" {
+		return n.Settings.Root
+	}
+	return n.Home
 }
 
 // Install fetch pre-build binary from the distribution and expand the compressed file in temp dir
-// and move the directory into configured directory.
-func (n *NVMG) Install(ver string) error {
-	filename := nodeBinaryArchiveName(ver)
-	dirname := ver
-	u, err := url.Parse(NodeDistributionURL)
+// and move the directory into configured directory. The download is split into concurrent, resumable
+// ranged requests when the server supports them (see downloadFile), and progress is reported to
+// n.ioout unless silent is true. The downloaded archive is checked against its published SHA-256
+// checksum before it is expanded; when verify is true its GPG signature is checked as well, provided
+// gpg is available on PATH and verifyssl is enabled.
+func (n *NVMG) Install(ref *dist.Ref, verify, silent bool) error {
+	filename := n.archiveName(ref)
+	dirname := ref.Version
+	u, err := url.Parse(n.distMirror(ref.Distribution))
 	if err != nil {
 		return err
 	}
@@ -199,20 +313,28 @@ func (n *NVMG) Install(ver string) error {
 	}
 	target := u.ResolveReference(p)
 	n.printfOut(target.String())
-	resp, err := http.Get(target.String())
+	client, err := n.httpClient()
 	if err != nil {
 		return err
 	}
+
 	downloaded := path.Join(os.TempDir(), filename)
-	file, err := os.Create(downloaded)
-	if err != nil {
+	if err := n.downloadFile(client, target, downloaded, silent); err != nil {
 		return err
 	}
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return pe.Wrapf(err, "failed to download file: %v", filename)
+
+	if err := n.verifyChecksum(client, u, dirname, filename, downloaded); err != nil {
+		os.Remove(downloaded)
+		return err
 	}
-	destDir := path.Join(n.Home, ver)
+	if verify && n.Settings.VerifySSL {
+		if err := n.verifyGPGSignature(client, u, dirname); err != nil {
+			os.Remove(downloaded)
+			return err
+		}
+	}
+
+	destDir := path.Join(n.versionRoot(), ref.Dir())
 	return expandArchiveFile(downloaded, destDir)
 }
 
@@ -261,10 +383,12 @@ func expandArchiveFile(filename, dest string) error {
 	return nil
 }
 
-// nodeBinaryArchinveName generates the filename of archive file uploaded on the distribution page.
+// archiveName generates the filename of the archive file uploaded on ref's distribution page.
 // The CPU architecture name and OS platform name are listed here:
 //    https://go.googlesource.com/go/+/master/src/go/build/syslist.go
-func nodeBinaryArchiveName(ver string) string {
+// n.Settings.Arch, when set, overrides the architecture nvmg would otherwise
+// infer from runtime.GOARCH - e.g. to install an x86 build on an x64 host.
+func (n *NVMG) archiveName(ref *dist.Ref) string {
 	var platform, arch, ext string
 	switch runtime.GOOS {
 	case "linux":
@@ -284,6 +408,11 @@ func nodeBinaryArchiveName(ver string) string {
 		ext = "tar.gz"
 	}
 
+	if n.Settings.Arch != "" {
+		arch = n.Settings.Arch
+		return ref.ArchiveName(platform, arch, ext)
+	}
+
 	// TODO: there's no easy way to get ARM version from runtime, so it requires some way to
 	// embed build target ARM version. This should be achieved in the same method as runtime.GOOS.
 	// (ref. https://go.googlesource.com/go/+/master/src/go/build/syslist.go)
@@ -304,5 +433,5 @@ func nodeBinaryArchiveName(ver string) string {
 		arch = "x64"
 	}
 
-	return fmt.Sprintf("node-%v-%v-%v.%v", ver, platform, arch, ext)
+	return ref.ArchiveName(platform, arch, ext)
 }
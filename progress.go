@@ -0,0 +1,102 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// progressTick is how often the progress bar redraws itself.
+const progressTick = 200 * time.Millisecond
+
+// progressReporter renders a single-line, carriage-return-driven progress
+// bar (percentage, throughput, ETA) to out as bytes are reported via add.
+// It is safe to call add concurrently from multiple goroutines, e.g. one per
+// download chunk. A silent reporter still counts bytes but renders nothing.
+type progressReporter struct {
+	out     io.Writer
+	total   int64
+	written int64
+	start   time.Time
+	silent  bool
+	done    chan struct{}
+}
+
+// newProgressReporter returns a progressReporter for a transfer of total
+// bytes (0 if unknown). Call run in its own goroutine to start rendering,
+// and finish once the transfer completes.
+func newProgressReporter(out io.Writer, total int64, silent bool) *progressReporter {
+	return &progressReporter{
+		out:    out,
+		total:  total,
+		start:  time.Now(),
+		silent: silent,
+		done:   make(chan struct{}),
+	}
+}
+
+// add records n additional bytes transferred.
+func (p *progressReporter) add(n int64) {
+	atomic.AddInt64(&p.written, n)
+}
+
+// run redraws the progress bar on a timer until finish is called. Meant to
+// be run in its own goroutine.
+func (p *progressReporter) run() {
+	if p.silent {
+		return
+	}
+	ticker := time.NewTicker(progressTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.render()
+		case <-p.done:
+			p.render()
+			fmt.Fprintln(p.out)
+			return
+		}
+	}
+}
+
+// finish stops run and leaves a final, fully up-to-date render in place.
+func (p *progressReporter) finish() {
+	close(p.done)
+}
+
+func (p *progressReporter) render() {
+	written := atomic.LoadInt64(&p.written)
+	elapsed := time.Since(p.start).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(written) / elapsed
+	}
+
+	eta := "--"
+	if rate > 0 && p.total > written {
+		eta = fmt.Sprintf("%.0fs", float64(p.total-written)/rate)
+	}
+
+	if p.total > 0 {
+		pct := float64(written) / float64(p.total) * 100
+		fmt.Fprintf(p.out, "\r%6.2f%%  %8.1f KB/s  ETA %-6v", pct, rate/1024, eta)
+		return
+	}
+	fmt.Fprintf(p.out, "\r%10d bytes  %8.1f KB/s", written, rate/1024)
+}
@@ -0,0 +1,149 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	pe "github.com/pkg/errors"
+
+	"github.com/ymotongpoo/nvmg/internal/dist"
+)
+
+// packagesExcludedFromReinstall are never copied across versions: npm and
+// corepack ship with node itself.
+var packagesExcludedFromReinstall = map[string]bool{
+	"npm":      true,
+	"corepack": true,
+}
+
+// npmGlobalPackage is a single entry from `npm ls -g --depth=0 --json`'s
+// "dependencies" map.
+type npmGlobalPackage struct {
+	Version string `json:"version"`
+}
+
+// npmLsOutput is the subset of `npm ls -g --depth=0 --json` this package cares about.
+type npmLsOutput struct {
+	Dependencies map[string]npmGlobalPackage `json:"dependencies"`
+}
+
+// listGlobalPackages runs `npm ls -g --depth=0 --json` under ref's bin
+// directory and returns its globally-installed package specifiers
+// (name@version), excluding npm and corepack.
+func (n *NVMG) listGlobalPackages(ref *dist.Ref) ([]string, error) {
+	npmPath := path.Join(n.versionRoot(), ref.Dir(), "bin", "npm")
+	out, err := exec.Command(npmPath, "ls", "-g", "--depth=0", "--json").Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, pe.Wrapf(err, "couldn't run %v", npmPath)
+		}
+	}
+	var parsed npmLsOutput
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, pe.Wrapf(err, "couldn't parse npm ls output from %v", ref)
+	}
+	specs := make([]string, 0, len(parsed.Dependencies))
+	for name, pkg := range parsed.Dependencies {
+		if packagesExcludedFromReinstall[name] {
+			continue
+		}
+		specs = append(specs, fmt.Sprintf("%v@%v", name, pkg.Version))
+	}
+	sort.Strings(specs)
+	return specs, nil
+}
+
+// ReinstallPackages reinstalls every globally-installed npm package found
+// under from's bin directory into to's, preserving the exact version each
+// package was at. It tries a single batch install first; if that fails it
+// retries package-by-package so one broken package doesn't block the rest,
+// and returns an aggregated error listing every package that still failed.
+func (n *NVMG) ReinstallPackages(from, to *dist.Ref, dryRun bool) error {
+	specs, err := n.listGlobalPackages(from)
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		n.printfOut(fmt.Sprintf("no global packages found in %v", from))
+		return nil
+	}
+
+	npmPath := path.Join(n.versionRoot(), to.Dir(), "bin", "npm")
+	if dryRun {
+		n.printfOut(fmt.Sprintf("%v %v", npmPath, strings.Join(npmInstallArgs(n, specs), " ")))
+		return nil
+	}
+
+	if err := npmInstallGlobal(n, npmPath, specs); err == nil {
+		return nil
+	}
+
+	var failed []string
+	for _, spec := range specs {
+		if err := npmInstallGlobal(n, npmPath, []string{spec}); err != nil {
+			failed = append(failed, fmt.Sprintf("%v: %v", spec, err))
+		}
+	}
+	if len(failed) > 0 {
+		return pe.Errorf("failed to reinstall %d package(s):\n%v", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// npmInstallArgs builds the argument list for a global npm install,
+// pointing at n.Settings.NpmMirror when one is configured.
+func npmInstallArgs(n *NVMG, specs []string) []string {
+	args := []string{"install", "-g"}
+	if n.Settings.NpmMirror != "" {
+		args = append(args, "--registry", n.Settings.NpmMirror)
+	}
+	return append(args, specs...)
+}
+
+func npmInstallGlobal(n *NVMG, npmPath string, specs []string) error {
+	cmd := exec.Command(npmPath, npmInstallArgs(n, specs)...)
+	cmd.Stdout = n.ioout
+	cmd.Stderr = n.ioerr
+	return cmd.Run()
+}
+
+// RunReinstallPackages parses the arguments for the `reinstall-packages`
+// subcommand and runs it accordingly, reinstalling the packages found in
+// the given version into the currently active one.
+func (n *NVMG) RunReinstallPackages() error {
+	flags := flag.NewFlagSet("reinstallPackagesFlags", flag.ExitOnError)
+	dryRunFlag := flags.Bool("dry-run", false, "Print the planned npm install command without executing it.")
+	iojsFlag := flags.Bool("iojs", false, "Resolve <version> as an io.js release instead of node.")
+	flags.Parse(n.args[2:])
+	if flags.NArg() < 1 {
+		return fmt.Errorf("not enough arguments for reinstall-packages: %v", flags.Args())
+	}
+	from, err := n.expandVersionNumber(flags.Arg(0), *iojsFlag)
+	if err != nil {
+		return err
+	}
+	to, err := n.Current()
+	if err != nil {
+		return err
+	}
+	return n.ReinstallPackages(from, to, *dryRunFlag)
+}
@@ -0,0 +1,235 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	pe "github.com/pkg/errors"
+
+	"github.com/ymotongpoo/nvmg/internal/dist"
+)
+
+// nvmgrcFileName is the name of the per-project file that pins a version,
+// analogous to nvm's .nvmrc.
+const nvmgrcFileName = ".nvmgrc"
+
+// symlinkPath returns the path nvmg maintains as a stable pointer to the
+// currently active node installation. It defaults to <versionRoot>/current
+// but can be overridden with NVMG_SYMLINK, following nvm-windows' NVM_SYMLINK.
+func (n *NVMG) symlinkPath() string {
+	if p := os.Getenv("NVMG_SYMLINK"); p != "" {
+		return p
+	}
+	return path.Join(n.versionRoot(), "current")
+}
+
+// Use atomically points the nvmg symlink at the bin directory of ref, so
+// that a PATH entry of the symlink itself (<versionRoot>/current by default) always resolves to the
+// active version without re-sourcing any shell script.
+func (n *NVMG) Use(ref *dist.Ref) error {
+	binDir := path.Join(n.versionRoot(), ref.Dir(), "bin")
+	if _, err := os.Stat(binDir); err != nil {
+		return pe.Wrapf(err, "%v is not installed", ref)
+	}
+	link := n.symlinkPath()
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(binDir, tmp); err != nil {
+		return pe.Wrapf(err, "couldn't create symlink to %v", binDir)
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		return pe.Wrapf(err, "couldn't activate %v", ref)
+	}
+	return nil
+}
+
+// Deactivate removes the nvmg symlink, undoing the effect of Use.
+func (n *NVMG) Deactivate() error {
+	link := n.symlinkPath()
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return pe.Wrapf(err, "couldn't remove %v", link)
+	}
+	return nil
+}
+
+// Current reads the nvmg symlink and resolves it back to the distribution
+// and version it points at.
+func (n *NVMG) Current() (*dist.Ref, error) {
+	link := n.symlinkPath()
+	target, err := os.Readlink(link)
+	if err != nil {
+		return nil, pe.Wrap(err, "no version is currently active")
+	}
+	// target looks like $NVMG_DIR/<ver>/bin for node, or
+	// $NVMG_DIR/iojs/<ver>/bin for io.js.
+	verDir := filepath.Dir(target)
+	ver := filepath.Base(verDir)
+	d := dist.Node
+	if filepath.Base(filepath.Dir(verDir)) == dist.IOJS.Name {
+		d = dist.IOJS
+	}
+	return &dist.Ref{Distribution: d, Version: ver}, nil
+}
+
+// findNvmgrc walks up from dir looking for a .nvmgrc file and returns its
+// trimmed contents.
+func findNvmgrc(dir string) (string, error) {
+	for {
+		b, err := ioutil.ReadFile(path.Join(dir, nvmgrcFileName))
+		if err == nil {
+			return strings.TrimSpace(string(b)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", pe.Errorf("no %v found", nvmgrcFileName)
+		}
+		dir = parent
+	}
+}
+
+// resolveRequestedVersion expands explicit when given; otherwise it falls
+// back to the descriptor recorded in .nvmgrc, walking up from the current
+// working directory. iojsFlag forces io.js regardless of how the descriptor
+// would otherwise be interpreted.
+func (n *NVMG) resolveRequestedVersion(explicit string, iojsFlag bool) (*dist.Ref, error) {
+	if explicit != "" {
+		return n.expandVersionNumber(explicit, iojsFlag)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := findNvmgrc(cwd)
+	if err != nil {
+		return nil, pe.Wrap(err, "no version specified and no .nvmgrc found")
+	}
+	return n.expandVersionNumber(descriptor, iojsFlag)
+}
+
+// RunUse parses the arguments for the `use` subcommand and runs it accordingly.
+func (n *NVMG) RunUse() error {
+	flags := flag.NewFlagSet("useFlags", flag.ExitOnError)
+	silentFlag := flags.Bool("silent", false, "Suppress output.")
+	iojsFlag := flags.Bool("iojs", false, "Use an io.js release instead of node.")
+	flags.Parse(n.args[2:])
+	ref, err := n.resolveRequestedVersion(flags.Arg(0), *iojsFlag)
+	if err != nil {
+		return err
+	}
+	if err := n.Use(ref); err != nil {
+		return err
+	}
+	if !*silentFlag {
+		n.printfOut(fmt.Sprintf("Now using %v", ref))
+	}
+	return nil
+}
+
+// RunCurrent parses the arguments for the `current` subcommand and runs it.
+func (n *NVMG) RunCurrent() error {
+	ref, err := n.Current()
+	if err != nil {
+		return err
+	}
+	n.printfOut(ref.String())
+	return nil
+}
+
+// RunWhich parses the arguments for the `which` subcommand and runs it.
+func (n *NVMG) RunWhich() error {
+	flags := flag.NewFlagSet("whichFlags", flag.ExitOnError)
+	iojsFlag := flags.Bool("iojs", false, "Resolve an io.js release instead of node.")
+	flags.Parse(n.args[2:])
+	ref, err := n.resolveRequestedVersion(flags.Arg(0), *iojsFlag)
+	if err != nil {
+		return err
+	}
+	n.printfOut(path.Join(n.versionRoot(), ref.Dir(), "bin", ref.Distribution.BinaryName))
+	return nil
+}
+
+// RunExec parses the arguments for the `exec` subcommand and runs it.
+func (n *NVMG) RunExec() error {
+	flags := flag.NewFlagSet("execFlags", flag.ExitOnError)
+	silentFlag := flags.Bool("silent", false, "Suppress nvmg's own output.")
+	iojsFlag := flags.Bool("iojs", false, "Resolve an io.js release instead of node.")
+	flags.Parse(n.args[2:])
+	ref, rest, err := n.splitVersionAndArgs(flags.Args(), *iojsFlag)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("no command given to exec")
+	}
+	return n.runWithVersion(ref, rest, *silentFlag)
+}
+
+// RunRun parses the arguments for the `run` subcommand and runs it.
+func (n *NVMG) RunRun() error {
+	flags := flag.NewFlagSet("runFlags", flag.ExitOnError)
+	silentFlag := flags.Bool("silent", false, "Suppress nvmg's own output.")
+	iojsFlag := flags.Bool("iojs", false, "Resolve an io.js release instead of node.")
+	flags.Parse(n.args[2:])
+	ref, rest, err := n.splitVersionAndArgs(flags.Args(), *iojsFlag)
+	if err != nil {
+		return err
+	}
+	return n.runWithVersion(ref, append([]string{ref.Distribution.BinaryName}, rest...), *silentFlag)
+}
+
+// splitVersionAndArgs separates a leading explicit version descriptor from
+// the rest of args, falling back to .nvmgrc when the first argument doesn't
+// resolve to a version (or there is no first argument at all).
+func (n *NVMG) splitVersionAndArgs(args []string, iojsFlag bool) (*dist.Ref, []string, error) {
+	if len(args) > 0 {
+		if ref, err := n.expandVersionNumber(args[0], iojsFlag); err == nil {
+			return ref, args[1:], nil
+		}
+	}
+	ref, err := n.resolveRequestedVersion("", iojsFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ref, args, nil
+}
+
+// runWithVersion executes commandAndArgs[0] from ref's bin directory with
+// the remaining entries as arguments, inheriting nvmg's own stdio.
+func (n *NVMG) runWithVersion(ref *dist.Ref, commandAndArgs []string, silent bool) error {
+	binDir := path.Join(n.versionRoot(), ref.Dir(), "bin")
+	if _, err := os.Stat(binDir); err != nil {
+		return pe.Wrapf(err, "%v is not installed", ref)
+	}
+	cmd := exec.Command(path.Join(binDir, commandAndArgs[0]), commandAndArgs[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = n.ioout
+	cmd.Stderr = n.ioerr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PATH=%v%c%v", binDir, os.PathListSeparator, os.Getenv("PATH")))
+	if !silent {
+		n.printfOut(fmt.Sprintf("Running %v with %v", commandAndArgs[0], ref))
+	}
+	return cmd.Run()
+}
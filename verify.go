@@ -0,0 +1,149 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	pe "github.com/pkg/errors"
+)
+
+const (
+	shasumsFileName    = "SHASUMS256.txt"
+	shasumsSigFileName = "SHASUMS256.txt.sig"
+)
+
+// verifyChecksum downloads SHASUMS256.txt from the same distribution directory
+// as filename and checks that downloaded's SHA-256 digest matches the entry
+// recorded there for filename.
+func (n *NVMG) verifyChecksum(client *http.Client, base *url.URL, dirname, filename, downloaded string) error {
+	sums, err := fetchDistFile(client, base, dirname, shasumsFileName)
+	if err != nil {
+		return pe.Wrapf(err, "couldn't fetch %v", shasumsFileName)
+	}
+	want, err := parseSHASums(sums, filename)
+	if err != nil {
+		return err
+	}
+	got, err := sha256File(downloaded)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return pe.Errorf("checksum mismatch for %v: want %v, got %v", filename, want, got)
+	}
+	return nil
+}
+
+// verifyGPGSignature verifies the SHASUMS256.txt.sig detached signature against
+// SHASUMS256.txt using the gpg binary on PATH, using the Node.js release-signing
+// keys the caller is expected to already have imported. It is a no-op if gpg
+// cannot be found.
+func (n *NVMG) verifyGPGSignature(client *http.Client, base *url.URL, dirname string) error {
+	gpgPath, err := exec.LookPath("gpg")
+	if err != nil {
+		return nil
+	}
+	sums, err := fetchDistFile(client, base, dirname, shasumsFileName)
+	if err != nil {
+		return pe.Wrapf(err, "couldn't fetch %v", shasumsFileName)
+	}
+	sig, err := fetchDistFile(client, base, dirname, shasumsSigFileName)
+	if err != nil {
+		return pe.Wrapf(err, "couldn't fetch %v", shasumsSigFileName)
+	}
+
+	tempDir, err := ioutil.TempDir("", "nvmg-gpg")
+	if err != nil {
+		return pe.Wrap(err, "couldn't create tempdir")
+	}
+	defer os.RemoveAll(tempDir)
+
+	sumsFile := path.Join(tempDir, shasumsFileName)
+	sigFile := path.Join(tempDir, shasumsSigFileName)
+	if err := ioutil.WriteFile(sumsFile, sums, os.FileMode(0644)); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(sigFile, sig, os.FileMode(0644)); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(gpgPath, "--verify", sigFile, sumsFile)
+	cmd.Stdout = n.ioout
+	cmd.Stderr = n.ioerr
+	if err := cmd.Run(); err != nil {
+		return pe.Wrap(err, "gpg signature verification failed")
+	}
+	return nil
+}
+
+// fetchDistFile downloads the file named name from dirname within base.
+func fetchDistFile(client *http.Client, base *url.URL, dirname, name string) ([]byte, error) {
+	p, err := url.Parse(path.Join("./", dirname, name))
+	if err != nil {
+		return nil, err
+	}
+	target := base.ResolveReference(p)
+	resp, err := client.Get(target.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, pe.Errorf("unexpected status fetching %v: %v", target.String(), resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// parseSHASums looks up the checksum entry matching filename in the contents
+// of a SHASUMS256.txt file.
+func parseSHASums(sums []byte, filename string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == filename {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", pe.Errorf("no checksum entry found for %v", filename)
+}
+
+// sha256File computes the hex-encoded SHA-256 digest of filename.
+func sha256File(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
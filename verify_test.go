@@ -0,0 +1,104 @@
+//    Copyright 2018 Yoshi Yamaguchi
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+)
+
+const fakeTarball = "this is definitely not a real tarball, just test fixture bytes"
+
+func newFakeDistServer(t *testing.T, dirname, filename string) *httptest.Server {
+	t.Helper()
+	checksum, err := sha256String(fakeTarball)
+	if err != nil {
+		t.Fatalf("couldn't compute fixture checksum: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"version":"%v","lts":false}]`, dirname)
+	})
+	mux.HandleFunc("/"+dirname+"/"+filename, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, fakeTarball)
+	})
+	mux.HandleFunc("/"+dirname+"/"+shasumsFileName, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%v  %v\n", checksum, filename)
+	})
+	return httptest.NewServer(mux)
+}
+
+func sha256String(s string) (string, error) {
+	f, err := ioutil.TempFile("", "nvmg-fixture")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(s); err != nil {
+		return "", err
+	}
+	f.Close()
+	return sha256File(f.Name())
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	const dirname, filename = "v1.0.0", "node-v1.0.0-linux-x64.tar.gz"
+	server := newFakeDistServer(t, dirname, filename)
+	defer server.Close()
+
+	base, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("couldn't parse server URL: %v", err)
+	}
+
+	downloaded := path.Join(os.TempDir(), filename)
+	if err := ioutil.WriteFile(downloaded, []byte(fakeTarball), os.FileMode(0644)); err != nil {
+		t.Fatalf("couldn't write fixture file: %v", err)
+	}
+	defer os.Remove(downloaded)
+
+	n := &NVMG{ioout: ioutil.Discard, ioerr: ioutil.Discard}
+	if err := n.verifyChecksum(server.Client(), base, dirname, filename, downloaded); err != nil {
+		t.Errorf("verifyChecksum() returned unexpected error: %v", err)
+	}
+}
+
+func TestVerifyChecksumMismatch(t *testing.T) {
+	const dirname, filename = "v1.0.0", "node-v1.0.0-linux-x64.tar.gz"
+	server := newFakeDistServer(t, dirname, filename)
+	defer server.Close()
+
+	base, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("couldn't parse server URL: %v", err)
+	}
+
+	downloaded := path.Join(os.TempDir(), filename)
+	if err := ioutil.WriteFile(downloaded, []byte("tampered contents"), os.FileMode(0644)); err != nil {
+		t.Fatalf("couldn't write fixture file: %v", err)
+	}
+	defer os.Remove(downloaded)
+
+	n := &NVMG{ioout: ioutil.Discard, ioerr: ioutil.Discard}
+	if err := n.verifyChecksum(server.Client(), base, dirname, filename, downloaded); err == nil {
+		t.Error("verifyChecksum() expected an error for tampered content, got nil")
+	}
+}